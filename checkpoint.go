@@ -0,0 +1,41 @@
+package main
+
+import (
+	"fmt"
+	"time"
+)
+
+//Checkpoint records the last feed entry successfully processed by the
+//walker so a subsequent run can resume without re-walking the whole feed
+//from the oldest archive.
+type Checkpoint struct {
+	FeedID      string    `json:"feedId"`
+	EntryID     string    `json:"entryId"`
+	UpdatedTime time.Time `json:"updatedTime"`
+}
+
+//CheckpointStore persists and retrieves the walker's Checkpoint.
+type CheckpointStore interface {
+	//Load returns the last saved Checkpoint, or nil if none has been
+	//saved yet.
+	Load() (*Checkpoint, error)
+	//Save persists cp as the new checkpoint.
+	Save(cp Checkpoint) error
+}
+
+//newCheckpointStore builds the CheckpointStore selected by the
+//CHECKPOINT_STORE env var, defaulting to a local JSON file. location is
+//backend-specific: a file path, an S3 "bucket/key", or a Postgres
+//connection string.
+func newCheckpointStore(kind, location string) (CheckpointStore, error) {
+	switch kind {
+	case "", "file":
+		return newFileCheckpointStore(location), nil
+	case "s3":
+		return newS3CheckpointStore(location)
+	case "postgres":
+		return newPostgresCheckpointStore(location)
+	default:
+		return nil, fmt.Errorf("unsupported CHECKPOINT_STORE %q", kind)
+	}
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+)
+
+//fileCheckpointStore persists the Checkpoint as JSON in a local file.
+//It's the default CheckpointStore and requires no external dependency.
+type fileCheckpointStore struct {
+	path string
+}
+
+//newFileCheckpointStore builds a fileCheckpointStore backed by path,
+//defaulting to "checkpoint.json" in the working directory.
+func newFileCheckpointStore(path string) *fileCheckpointStore {
+	if path == "" {
+		path = "checkpoint.json"
+	}
+
+	return &fileCheckpointStore{path: path}
+}
+
+//Load implements CheckpointStore.
+func (f *fileCheckpointStore) Load() (*Checkpoint, error) {
+	data, err := ioutil.ReadFile(f.path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var cp Checkpoint
+	if err := json.Unmarshal(data, &cp); err != nil {
+		return nil, err
+	}
+
+	return &cp, nil
+}
+
+//Save implements CheckpointStore. It writes to a temp file in the same
+//directory and renames over f.path so a crash or kill mid-write can't
+//leave a truncated checkpoint.json behind -- os.Rename is atomic within
+//a filesystem, unlike a direct WriteFile.
+func (f *fileCheckpointStore) Save(cp Checkpoint) error {
+	data, err := json.Marshal(cp)
+	if err != nil {
+		return err
+	}
+
+	tmp, err := ioutil.TempFile(filepath.Dir(f.path), filepath.Base(f.path)+".tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+
+	if err := os.Chmod(tmp.Name(), 0644); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp.Name(), f.path)
+}
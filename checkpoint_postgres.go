@@ -0,0 +1,62 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+
+	_ "github.com/lib/pq"
+)
+
+//postgresCheckpointStore persists the Checkpoint in a Postgres table.
+//location is a standard "postgres://..." connection string. The table is
+//expected to already exist:
+//
+//	create table dump_eventstore_checkpoint (id int primary key, data jsonb)
+type postgresCheckpointStore struct {
+	db *sql.DB
+}
+
+//newPostgresCheckpointStore builds a CheckpointStore backed by Postgres.
+func newPostgresCheckpointStore(location string) (CheckpointStore, error) {
+	db, err := sql.Open("postgres", location)
+	if err != nil {
+		return nil, err
+	}
+
+	return &postgresCheckpointStore{db: db}, nil
+}
+
+//Load implements CheckpointStore.
+func (p *postgresCheckpointStore) Load() (*Checkpoint, error) {
+	var data []byte
+
+	err := p.db.QueryRow("select data from dump_eventstore_checkpoint where id = 1").Scan(&data)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var cp Checkpoint
+	if err := json.Unmarshal(data, &cp); err != nil {
+		return nil, err
+	}
+
+	return &cp, nil
+}
+
+//Save implements CheckpointStore.
+func (p *postgresCheckpointStore) Save(cp Checkpoint) error {
+	data, err := json.Marshal(cp)
+	if err != nil {
+		return err
+	}
+
+	_, err = p.db.Exec(`
+		insert into dump_eventstore_checkpoint (id, data) values (1, $1)
+		on conflict (id) do update set data = excluded.data
+	`, data)
+
+	return err
+}
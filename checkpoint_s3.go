@@ -0,0 +1,89 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/s3"
+)
+
+//s3CheckpointStore persists the Checkpoint as a JSON object in S3.
+//location is of the form "bucket/key".
+type s3CheckpointStore struct {
+	svc    *s3.S3
+	bucket string
+	key    string
+}
+
+//newS3CheckpointStore builds a CheckpointStore backed by S3.
+func newS3CheckpointStore(location string) (CheckpointStore, error) {
+	bucket, key, err := splitS3Location(location)
+	if err != nil {
+		return nil, err
+	}
+
+	sess, err := session.NewSession()
+	if err != nil {
+		return nil, err
+	}
+
+	return &s3CheckpointStore{svc: s3.New(sess), bucket: bucket, key: key}, nil
+}
+
+func splitS3Location(location string) (bucket, key string, err error) {
+	parts := strings.SplitN(location, "/", 2)
+	if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+		return "", "", fmt.Errorf("expected CHECKPOINT_LOCATION of the form bucket/key, got %q", location)
+	}
+
+	return parts[0], parts[1], nil
+}
+
+//Load implements CheckpointStore.
+func (s *s3CheckpointStore) Load() (*Checkpoint, error) {
+	out, err := s.svc.GetObject(&s3.GetObjectInput{
+		Bucket: aws.String(s.bucket),
+		Key:    aws.String(s.key),
+	})
+	if aerr, ok := err.(awserr.Error); ok && aerr.Code() == s3.ErrCodeNoSuchKey {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer out.Body.Close()
+
+	data, err := ioutil.ReadAll(out.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	var cp Checkpoint
+	if err := json.Unmarshal(data, &cp); err != nil {
+		return nil, err
+	}
+
+	return &cp, nil
+}
+
+//Save implements CheckpointStore.
+func (s *s3CheckpointStore) Save(cp Checkpoint) error {
+	data, err := json.Marshal(cp)
+	if err != nil {
+		return err
+	}
+
+	_, err = s.svc.PutObject(&s3.PutObjectInput{
+		Bucket: aws.String(s.bucket),
+		Key:    aws.String(s.key),
+		Body:   bytes.NewReader(data),
+	})
+
+	return err
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"os"
+	"strconv"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+)
+
+//envInt reads an integer environment variable, falling back to def if the
+//variable is unset or not a valid integer.
+func envInt(name string, def int) int {
+	v := os.Getenv(name)
+	if v == "" {
+		return def
+	}
+
+	n, err := strconv.Atoi(v)
+	if err != nil {
+		log.Warnf("Ignoring invalid %s=%q: %s", name, v, err.Error())
+		return def
+	}
+
+	return n
+}
+
+//envDuration reads a duration environment variable (e.g. "5m"), falling
+//back to def if the variable is unset or not a valid duration.
+func envDuration(name string, def time.Duration) time.Duration {
+	v := os.Getenv(name)
+	if v == "" {
+		return def
+	}
+
+	d, err := time.ParseDuration(v)
+	if err != nil {
+		log.Warnf("Ignoring invalid %s=%q: %s", name, v, err.Error())
+		return def
+	}
+
+	return d
+}
+
+//envFloat reads a floating point environment variable, falling back to
+//def if the variable is unset or not a valid number.
+func envFloat(name string, def float64) float64 {
+	v := os.Getenv(name)
+	if v == "" {
+		return def
+	}
+
+	f, err := strconv.ParseFloat(v, 64)
+	if err != nil {
+		log.Warnf("Ignoring invalid %s=%q: %s", name, v, err.Error())
+		return def
+	}
+
+	return f
+}
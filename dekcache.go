@@ -0,0 +1,111 @@
+package main
+
+import (
+	"container/list"
+	"crypto/sha256"
+	"sync"
+	"time"
+)
+
+//dekCacheEntry is the value stored per cache slot: the unsealed data key,
+//the lookup key it was filed under (needed to evict from the map when the
+//list entry is dropped), and when it expires.
+type dekCacheEntry struct {
+	lookupKey string
+	dataKey   []byte
+	expiresAt time.Time
+}
+
+//dekCache is a small LRU cache of unsealed data encryption keys, keyed by
+//a hash of the still-sealed ciphertext blob. Many feed pages share a KEK,
+//so caching here avoids one KeyService round trip per archive page when
+//walking hundreds of feeds. A zero-value *dekCache (or nil) behaves as a
+//disabled cache.
+type dekCache struct {
+	mu       sync.Mutex
+	capacity int
+	ttl      time.Duration
+	ll       *list.List
+	items    map[string]*list.Element
+}
+
+//newDEKCache builds a dekCache holding at most capacity entries, each
+//valid for ttl (zero means entries never expire on their own). capacity
+//<= 0 disables caching.
+func newDEKCache(capacity int, ttl time.Duration) *dekCache {
+	return &dekCache{
+		capacity: capacity,
+		ttl:      ttl,
+		ll:       list.New(),
+		items:    make(map[string]*list.Element),
+	}
+}
+
+func dekCacheKey(ciphertextBlob []byte) string {
+	sum := sha256.Sum256(ciphertextBlob)
+	return string(sum[:])
+}
+
+//get returns the cached data key for ciphertextBlob, if present and not
+//expired.
+func (c *dekCache) get(ciphertextBlob []byte) ([]byte, bool) {
+	if c == nil || c.capacity <= 0 {
+		return nil, false
+	}
+
+	k := dekCacheKey(ciphertextBlob)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	el, ok := c.items[k]
+	if !ok {
+		return nil, false
+	}
+
+	entry := el.Value.(*dekCacheEntry)
+	if c.ttl > 0 && time.Now().After(entry.expiresAt) {
+		c.ll.Remove(el)
+		delete(c.items, k)
+		return nil, false
+	}
+
+	c.ll.MoveToFront(el)
+	return entry.dataKey, true
+}
+
+//put records the unsealed data key for ciphertextBlob, evicting the
+//least-recently-used entry if the cache is at capacity.
+func (c *dekCache) put(ciphertextBlob, dataKey []byte) {
+	if c == nil || c.capacity <= 0 {
+		return
+	}
+
+	k := dekCacheKey(ciphertextBlob)
+	expiresAt := time.Time{}
+	if c.ttl > 0 {
+		expiresAt = time.Now().Add(c.ttl)
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if el, ok := c.items[k]; ok {
+		c.ll.MoveToFront(el)
+		entry := el.Value.(*dekCacheEntry)
+		entry.dataKey = dataKey
+		entry.expiresAt = expiresAt
+		return
+	}
+
+	el := c.ll.PushFront(&dekCacheEntry{lookupKey: k, dataKey: dataKey, expiresAt: expiresAt})
+	c.items[k] = el
+
+	if c.ll.Len() > c.capacity {
+		oldest := c.ll.Back()
+		if oldest != nil {
+			c.ll.Remove(oldest)
+			delete(c.items, oldest.Value.(*dekCacheEntry).lookupKey)
+		}
+	}
+}
@@ -1,10 +1,9 @@
 package main
 
 import (
+	"context"
 	"crypto/aes"
 	"crypto/cipher"
-	"crypto/tls"
-	"encoding/base64"
 	"encoding/xml"
 	"errors"
 	"fmt"
@@ -12,58 +11,78 @@ import (
 	"net/http"
 	"net/url"
 	"os"
+	"os/signal"
 	"strings"
+	"time"
 
 	log "github.com/Sirupsen/logrus"
-	"github.com/aws/aws-sdk-go/aws/session"
-	"github.com/aws/aws-sdk-go/service/kms"
+	"golang.org/x/time/rate"
 	"golang.org/x/tools/blog/atom"
 )
 
 //HttpFeedReader defines a type for an Http Feed reader
 type HttpFeedReader struct {
-	endpoint string
-	client   *http.Client
-	proto    string
-	keyAlias string
-	kmsSvc   *kms.KMS
+	endpoint      string
+	client        *http.Client
+	proto         string
+	keyAlias      string
+	keySvc        KeyService
+	envelopeCodec EnvelopeCodec
+	dekCache      *dekCache
+	sigVerifier   SignatureVerifier
+	limiter       *rate.Limiter
 }
 
-//NewHttpFeedReader is a factory for instantiating HttpFeedReaders
-func NewHttpFeedReader(endpoint, feedProto, keyAlias string, kmsSvc *kms.KMS) *HttpFeedReader {
+//NewHttpFeedReader is a factory for instantiating HttpFeedReaders. For
+//https endpoints it builds a dedicated *http.Transport from tlsConfig --
+//it never mutates http.DefaultTransport. A nil sigVerifier disables
+//signature verification. limiter throttles the rate of requests this
+//reader issues; pass rate.NewLimiter(rate.Inf, 0) for no throttling.
+func NewHttpFeedReader(endpoint, feedProto, keyAlias string, keySvc KeyService, envelopeCodec EnvelopeCodec, dekCache *dekCache, tlsConfig TLSConfig, sigVerifier SignatureVerifier, limiter *rate.Limiter) (*HttpFeedReader, error) {
 
 	client := http.DefaultClient
 	if feedProto == "https" {
-		tr := http.DefaultTransport
-		defTransAsTransPort := tr.(*http.Transport)
-		defTransAsTransPort.TLSClientConfig = &tls.Config{InsecureSkipVerify: true}
-		client = &http.Client{Transport: tr}
+		tlsCfg, err := tlsConfig.Build()
+		if err != nil {
+			return nil, err
+		}
+
+		tr, err := newHTTPTransport(tlsCfg)
+		if err != nil {
+			return nil, err
+		}
+
+		client = &http.Client{Transport: tr, Timeout: 30 * time.Second}
 	}
 
 	return &HttpFeedReader{
-		endpoint: endpoint,
-		client:   client,
-		proto:    feedProto,
-		keyAlias: keyAlias,
-		kmsSvc:   kmsSvc,
-	}
+		endpoint:      endpoint,
+		client:        client,
+		proto:         feedProto,
+		keyAlias:      keyAlias,
+		keySvc:        keySvc,
+		envelopeCodec: envelopeCodec,
+		dekCache:      dekCache,
+		sigVerifier:   sigVerifier,
+		limiter:       limiter,
+	}, nil
 }
 
-//GetRecent returns the recent notifications
-func (hr *HttpFeedReader) GetRecent() (*atom.Feed, error) {
+//GetRecent returns the recent notifications.
+func (hr *HttpFeedReader) GetRecent(ctx context.Context) (*atom.Feed, error) {
 	url := fmt.Sprintf("%s://%s/notifications/recent", hr.proto, hr.endpoint)
-	return hr.getResource(url)
+	return hr.getResource(ctx, url)
 }
 
-//GetFeed returns the specific feed
-func (hr *HttpFeedReader) GetFeed(feedid string) (*atom.Feed, error) {
+//GetFeed returns the specific feed.
+func (hr *HttpFeedReader) GetFeed(ctx context.Context, feedid string) (*atom.Feed, error) {
 	url := fmt.Sprintf("%s://%s/notifications/%s", hr.proto, hr.endpoint, feedid)
-	return hr.getResource(url)
+	return hr.getResource(ctx, url)
 }
 
 //IsFeedEncrypted indicates if we use a key alias for decrypting the feed
 func (hr *HttpFeedReader) IsFeedEncrypted() bool {
-	return hr.keyAlias != ""
+	return hr.keySvc != nil
 }
 
 //Decrypt from cryptopasta commit bc3a108a5776376aa811eea34b93383837994340
@@ -90,47 +109,46 @@ func (hr *HttpFeedReader) decrypt(ciphertext []byte, key *[32]byte) (plaintext [
 	)
 }
 
-//DecryptFeed uses the AWS KMS to decrypt the feed text.
+//DecryptFeed decrypts the feed text, using the reader's EnvelopeCodec to
+//recover the sealed data key and ciphertext from the wire format, and its
+//KeyService to unseal the data key. Unsealed data keys are cached by
+//ciphertext-blob hash so that pages sharing a KEK don't each cost a
+//KeyService round trip.
 func (hr *HttpFeedReader) DecryptFeed(feedBytes []byte) ([]byte, error) {
-	//Message is encrypted encryption key + :: + encrypted message
-	parts := strings.Split(string(feedBytes), "::")
-	if len(parts) != 2 {
-		err := errors.New(fmt.Sprintf("Expected two parts, got %d", len(parts)))
-		return nil, err
-	}
-
-	//Decode the key and the text
-	keyBytes, err := base64.StdEncoding.DecodeString(parts[0])
+	envelope, err := hr.envelopeCodec.Decode(feedBytes)
 	if err != nil {
 		return nil, err
 	}
 
-	//Get the encrypted bytes
-	msgBytes, err := base64.StdEncoding.DecodeString(parts[1])
-	if err != nil {
-		return nil, err
-	}
+	decryptedKey, cached := hr.dekCache.get(envelope.EncryptedKey)
+	if cached {
+		dekCacheHits.Add(1)
+	} else {
+		dekCacheMisses.Add(1)
+		keyServiceCalls.Add(1)
 
-	//Decrypt the encryption key
-	di := &kms.DecryptInput{
-		CiphertextBlob: keyBytes,
-	}
+		decryptedKey, err = hr.keySvc.UnsealDataKey(context.Background(), envelope.EncryptedKey, envelope.AAD)
+		if err != nil {
+			return nil, err
+		}
 
-	decryptedKey, err := hr.kmsSvc.Decrypt(di)
-	if err != nil {
-		return nil, err
+		hr.dekCache.put(envelope.EncryptedKey, decryptedKey)
 	}
 
 	//Use the decrypted key to decrypt the message text
-	decryptKey := [32]byte{}
+	if len(decryptedKey) < 32 {
+		return nil, fmt.Errorf("unsealed data key is %d bytes, want at least 32", len(decryptedKey))
+	}
 
-	copy(decryptKey[:], decryptedKey.Plaintext[0:32])
+	decryptKey := [32]byte{}
+	copy(decryptKey[:], decryptedKey[0:32])
 
-	return hr.decrypt(msgBytes, &decryptKey)
+	return hr.decrypt(envelope.Ciphertext, &decryptKey)
 }
 
-//getResource does a git on the specified feed resource
-func (hr *HttpFeedReader) getResource(url string) (*atom.Feed, error) {
+//getResource does a git on the specified feed resource, returning the
+//parsed feed.
+func (hr *HttpFeedReader) getResource(ctx context.Context, url string) (*atom.Feed, error) {
 
 	log.Infof("Get %s", url)
 	req, err := http.NewRequest("GET", url, nil)
@@ -138,8 +156,7 @@ func (hr *HttpFeedReader) getResource(url string) (*atom.Feed, error) {
 		return nil, err
 	}
 
-	resp, err := hr.client.Do(req)
-
+	resp, err := hr.doRequestWithRetry(ctx, req)
 	if err != nil {
 		return nil, err
 	}
@@ -154,12 +171,14 @@ func (hr *HttpFeedReader) getResource(url string) (*atom.Feed, error) {
 		return nil, errors.New(fmt.Sprintf("Error retrieving resource: %d", resp.StatusCode))
 	}
 
-	responseBytes, err := ioutil.ReadAll(resp.Body)
+	wireBytes, err := ioutil.ReadAll(resp.Body)
 
 	if err != nil {
 		return nil, err
 	}
 
+	responseBytes := wireBytes
+
 	//Are we using a key to decrypt the feed?
 	if hr.IsFeedEncrypted() {
 		responseBytes, err = hr.DecryptFeed(responseBytes)
@@ -176,35 +195,150 @@ func (hr *HttpFeedReader) getResource(url string) (*atom.Feed, error) {
 		return nil, err
 	}
 
+	//Signature verification covers the raw wire bytes, before decryption.
+	//A feed page that fails verification has its entries dropped rather
+	//than surfaced to consumers, but its links are kept so next-archive
+	//navigation and checkpointing can still proceed.
+	if hr.sigVerifier != nil {
+		if err := hr.sigVerifier.Verify(wireBytes, resp.Header.Get("X-Feed-Signature")); err != nil {
+			log.Errorf("Signature verification failed for %s: %s", url, err.Error())
+			signatureVerificationFailures.Add(1)
+			feed.Entry = nil
+		}
+	}
+
 	return &feed, nil
 }
 
+//doRequestWithRetry waits for hr.limiter before each attempt, then
+//executes req, retrying a transient 5xx or 429 response with exponential
+//backoff and jitter (honoring a Retry-After header when present), making
+//at most maxFetchRetries attempts in all. It gives up immediately on ctx
+//cancellation.
+func (hr *HttpFeedReader) doRequestWithRetry(ctx context.Context, req *http.Request) (*http.Response, error) {
+	var lastErr error
+
+	for attempt := 0; attempt < maxFetchRetries; attempt++ {
+		if err := hr.limiter.Wait(ctx); err != nil {
+			return nil, err
+		}
+
+		resp, err := hr.client.Do(req.WithContext(ctx))
+		if err != nil {
+			return nil, err
+		}
+
+		if !isRetryableStatus(resp.StatusCode) {
+			return resp, nil
+		}
+
+		lastErr = fmt.Errorf("retryable status %d from %s", resp.StatusCode, req.URL)
+		resp.Body.Close()
+
+		if attempt == maxFetchRetries-1 {
+			break
+		}
+
+		delay := retryAfterDelay(resp.Header.Get("Retry-After"))
+		if delay == 0 {
+			delay = backoffWithJitter(attempt)
+		}
+		logRetry(req.URL.String(), resp.StatusCode, attempt, maxFetchRetries, delay)
+
+		select {
+		case <-time.After(delay):
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+
+	return nil, fmt.Errorf("giving up after %d attempts: %s", maxFetchRetries, lastErr.Error())
+}
+
 func createFeedReader() (*HttpFeedReader, error) {
 	feedAddr := os.Getenv("ATOMFEED_ENDPOINT")
 	if feedAddr == "" {
 		return nil, errors.New("Missing ATOMFEED_ENDPOINT environment variable value")
 	}
 
-	var kmsService *kms.KMS
+	var keySvc KeyService
 	keyAlias := os.Getenv("KEY_ALIAS")
-	if keyAlias != "" {
-		log.Info("Configuration indicates use of KMS with alias ", keyAlias)
+	keyURI := os.Getenv("KEY_URI")
+	if keyAlias != "" || keyURI != "" {
+		serviceKind := os.Getenv("KEY_SERVICE")
+		log.Infof("Configuration indicates use of key service %q", serviceKind)
+
+		//KEY_ALIAS doubles as the KEY_URI for the default AWS KMS backend
+		//so existing deployments keep working unmodified.
+		uri := keyURI
+		if uri == "" {
+			uri = keyAlias
+		}
 
-		sess, err := session.NewSession()
+		var err error
+		keySvc, err = newKeyService(serviceKind, uri)
 		if err != nil {
-			log.Errorf("Unable to establish AWS session: %s. Exiting.", err.Error())
+			log.Errorf("Unable to initialize key service: %s. Exiting.", err.Error())
 			os.Exit(1)
 		}
-		kmsService = kms.New(sess)
+
+		//Tolerate KEK rotation: retry against previously-valid key
+		//aliases when the current one fails to unseal a data key.
+		keySvc = withFallbackAliases(keySvc, serviceKind, os.Getenv("KEY_ALIAS_FALLBACKS"))
 	}
 
+	envelopeCodec, err := newEnvelopeCodec(os.Getenv("ENVELOPE_FORMAT"))
+	if err != nil {
+		log.Errorf("Unable to initialize envelope codec: %s. Exiting.", err.Error())
+		os.Exit(1)
+	}
+
+	cache := newDEKCache(envInt("DEK_CACHE_SIZE", 128), envDuration("DEK_CACHE_TTL", 5*time.Minute))
+	maybeServeMetrics(os.Getenv("METRICS_ADDR"))
+
 	proto := os.Getenv("FEED_PROTO")
 	if proto == "" {
 		log.Info("Defaulting feed proto to https")
 		proto = "https"
 	}
 
-	return NewHttpFeedReader(feedAddr, proto, keyAlias, kmsService), nil
+	sigVerifier, err := newSignatureVerifier()
+	if err != nil {
+		log.Errorf("Unable to initialize signature verifier: %s. Exiting.", err.Error())
+		os.Exit(1)
+	}
+
+	return NewHttpFeedReader(feedAddr, proto, keyAlias, keySvc, envelopeCodec, cache, newTLSConfigFromEnv(), sigVerifier, newRateLimiterFromEnv())
+}
+
+//withFallbackAliases wraps keySvc so that decrypt attempts retry against
+//the comma-separated key aliases in fallbackAliases, built using the same
+//backend kind, before giving up.
+func withFallbackAliases(keySvc KeyService, serviceKind, fallbackAliases string) KeyService {
+	if fallbackAliases == "" {
+		return keySvc
+	}
+
+	var fallbacks []KeyService
+	for _, alias := range strings.Split(fallbackAliases, ",") {
+		alias = strings.TrimSpace(alias)
+		if alias == "" {
+			continue
+		}
+
+		fb, err := newKeyService(serviceKind, alias)
+		if err != nil {
+			log.Errorf("Unable to initialize fallback key service for alias %s: %s", alias, err.Error())
+			os.Exit(1)
+		}
+		fallbacks = append(fallbacks, fb)
+	}
+
+	if len(fallbacks) == 0 {
+		return keySvc
+	}
+
+	return &fallbackKeyService{primary: keySvc, fallbacks: fallbacks}
 }
 
 //Get link extracts the given link relationship from the given feed's
@@ -232,13 +366,13 @@ func feedIdFromResource(feedURL string) string {
 
 //Get first feed navigates a feed set from the recent feed all the way back
 //to the first acchived feed
-func getFirstFeed(feedReader *HttpFeedReader) (*atom.Feed, error) {
+func getFirstFeed(ctx context.Context, feedReader *HttpFeedReader) (*atom.Feed, error) {
 	log.Info("Looking for first feed")
 	//Start with recent
 	var feed *atom.Feed
 	var feedReadError error
 
-	feed, feedReadError = feedReader.GetRecent()
+	feed, feedReadError = feedReader.GetRecent(ctx)
 	if feedReadError != nil {
 		return nil, feedReadError
 	}
@@ -259,7 +393,7 @@ func getFirstFeed(feedReader *HttpFeedReader) (*atom.Feed, error) {
 		//Extract feed id from prev
 		feedID := feedIdFromResource(*prev)
 		log.Infof("Prev archive feed id is %s", feedID)
-		feed, feedReadError = feedReader.GetFeed(feedID)
+		feed, feedReadError = feedReader.GetFeed(ctx, feedID)
 		if feedReadError != nil {
 			return nil, feedReadError
 		}
@@ -269,40 +403,111 @@ func getFirstFeed(feedReader *HttpFeedReader) (*atom.Feed, error) {
 }
 
 func main() {
+	flags := parseWalkFlags()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	interrupts := make(chan os.Signal, 1)
+	signal.Notify(interrupts, os.Interrupt)
+	go func() {
+		<-interrupts
+		log.Info("Interrupted, finishing current archive and stopping")
+		cancel()
+	}()
+
 	feedReader, err := createFeedReader()
 	if err != nil {
 		log.Fatalf("Error creating feed reader: %s", err.Error())
 	}
 
-	first, err := getFirstFeed(feedReader)
+	checkpointStore, err := newCheckpointStore(os.Getenv("CHECKPOINT_STORE"), os.Getenv("CHECKPOINT_LOCATION"))
 	if err != nil {
-		log.Fatalf("Read: %s", err.Error())
+		log.Fatalf("Error creating checkpoint store: %s", err.Error())
 	}
 
-	log.Info(first.Link)
-	for _, entry := range first.Entry {
-		fmt.Printf("%s %s\n", entry.ID, entry.Content.Body)
+	sink, err := newEntrySink(os.Getenv("OUTPUT_SINK"))
+	if err != nil {
+		log.Fatalf("Error creating entry sink: %s", err.Error())
 	}
+	defer sink.Close()
 
-	feed := first
-	var feedReadError error
-	for {
-		next := getLink("next-archive", feed)
-		if next == nil {
-			break
-		}
+	cp, err := checkpointStore.Load()
+	if err != nil {
+		log.Fatalf("Error loading checkpoint: %s", err.Error())
+	}
 
-		//Extract feed id from prev
-		feedID := feedIdFromResource(*next)
-		log.Infof("Next archive feed id is %s", feedID)
-		feed, feedReadError = feedReader.GetFeed(feedID)
-		if feedReadError != nil {
-			log.Fatal(feedReadError.Error())
+	//skipEntryID is the last entry already delivered on the resumed
+	//feed page; entries up to and including it are skipped so a resumed
+	//at-most-once run doesn't re-deliver them.
+	var feed *atom.Feed
+	skipEntryID := ""
+
+	if cp != nil {
+		log.Infof("Resuming from checkpoint: feed %s, entry %s", cp.FeedID, cp.EntryID)
+		feed, err = feedReader.GetFeed(ctx, cp.FeedID)
+		if err != nil {
+			log.Fatalf("Error resuming feed %s: %s", cp.FeedID, err.Error())
+		}
+		skipEntryID = cp.EntryID
+	} else {
+		feed, err = getFirstFeed(ctx, feedReader)
+		if err != nil {
+			log.Fatalf("Read: %s", err.Error())
 		}
+	}
+
+	if feed == nil {
+		log.Info("Nothing in the feed")
+		return
+	}
+
+	log.Info(feed.Link)
+
+	walker := NewFeedWalker(feedReader, envInt("ARCHIVE_PREFETCH", 4))
+
+	err = walker.Walk(ctx, feed, func(feed *atom.Feed) error {
+		feedID := currentFeedID(feed)
+		skipping := skipEntryID != ""
 
 		for _, entry := range feed.Entry {
-			fmt.Printf("%s %s %s %s\n", entry.ID, entry.Content.Body, entry.Published, entry.Content.Type)
+			if skipping {
+				if entry.ID == skipEntryID {
+					skipping = false
+				}
+				continue
+			}
+
+			if !withinBounds(&entry, flags.from, flags.to) {
+				continue
+			}
+
+			if flags.mode == atMostOnce {
+				if err := checkpointStore.Save(Checkpoint{FeedID: feedID, EntryID: entry.ID, UpdatedTime: time.Now()}); err != nil {
+					log.Errorf("Error saving checkpoint: %s", err.Error())
+				}
+			}
+
+			if err := sink.Write(&entry); err != nil {
+				log.Errorf("Error writing entry %s to sink: %s", entry.ID, err.Error())
+			}
 		}
-	}
 
+		if flags.mode != atMostOnce && len(feed.Entry) > 0 {
+			last := feed.Entry[len(feed.Entry)-1]
+			if err := checkpointStore.Save(Checkpoint{FeedID: feedID, EntryID: last.ID, UpdatedTime: time.Now()}); err != nil {
+				log.Errorf("Error saving checkpoint: %s", err.Error())
+			}
+		}
+
+		//skipEntryID only applies to the resumed page; later pages in
+		//the walk are fresh.
+		skipEntryID = ""
+
+		return nil
+	})
+
+	if err != nil && err != context.Canceled {
+		log.Fatal(err.Error())
+	}
 }
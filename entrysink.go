@@ -0,0 +1,37 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"golang.org/x/tools/blog/atom"
+)
+
+//EntrySink receives each parsed atom.Entry as the walker emits it.
+//Signature verification already happened against the page's raw wire
+//bytes before its entries were handed to a sink -- see HttpFeedReader's
+//sigVerifier -- so a sink only ever sees entries that already passed.
+type EntrySink interface {
+	Write(entry *atom.Entry) error
+	Close() error
+}
+
+//newEntrySink builds the EntrySink selected by the OUTPUT_SINK env var,
+//defaulting to NDJSON on stdout -- the original behavior, just
+//structured.
+func newEntrySink(kind string) (EntrySink, error) {
+	switch kind {
+	case "", "ndjson", "stdout":
+		return newNDJSONSink(os.Stdout), nil
+	case "dir":
+		return newDirectorySink(os.Getenv("OUTPUT_DIR"))
+	case "imap":
+		return newIMAPSink()
+	case "kafka":
+		return newKafkaSink()
+	case "nats":
+		return newNATSSink()
+	default:
+		return nil, fmt.Errorf("unsupported OUTPUT_SINK %q", kind)
+	}
+}
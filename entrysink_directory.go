@@ -0,0 +1,54 @@
+package main
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"golang.org/x/tools/blog/atom"
+)
+
+//directorySink writes one JSON file per entry, named by entry ID, so the
+//output can be explored with plain grep/jq.
+type directorySink struct {
+	dir string
+}
+
+//newDirectorySink builds a directorySink rooted at dir, defaulting to
+//"entries" in the working directory, creating it if necessary.
+func newDirectorySink(dir string) (*directorySink, error) {
+	if dir == "" {
+		dir = "entries"
+	}
+
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+
+	return &directorySink{dir: dir}, nil
+}
+
+//Write implements EntrySink.
+func (s *directorySink) Write(entry *atom.Entry) error {
+	data, err := json.MarshalIndent(newNDJSONRecord(entry), "", "  ")
+	if err != nil {
+		return err
+	}
+
+	path := filepath.Join(s.dir, sanitizeFileName(entry.ID)+".json")
+	return ioutil.WriteFile(path, data, 0644)
+}
+
+//Close implements EntrySink.
+func (s *directorySink) Close() error {
+	return nil
+}
+
+//sanitizeFileName replaces path separators in an entry ID so it can be
+//used as a file name.
+func sanitizeFileName(id string) string {
+	replacer := strings.NewReplacer("/", "_", "\\", "_", ":", "_")
+	return replacer.Replace(id)
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"mime"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/emersion/go-imap/client"
+	"golang.org/x/tools/blog/atom"
+)
+
+//imapSink turns each atom.Entry into an email message and appends it to
+//an IMAP mailbox, in the spirit of feed2imap-style delivery.
+type imapSink struct {
+	client  *client.Client
+	mailbox string
+	from    string
+}
+
+//newIMAPSink builds an imapSink from the IMAP_ADDR, IMAP_USER,
+//IMAP_PASSWORD, IMAP_MAILBOX, and IMAP_FROM env vars.
+func newIMAPSink() (*imapSink, error) {
+	addr := os.Getenv("IMAP_ADDR")
+	if addr == "" {
+		return nil, fmt.Errorf("IMAP_ADDR must be set to use the imap sink")
+	}
+
+	c, err := client.DialTLS(addr, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := c.Login(os.Getenv("IMAP_USER"), os.Getenv("IMAP_PASSWORD")); err != nil {
+		return nil, err
+	}
+
+	mailbox := os.Getenv("IMAP_MAILBOX")
+	if mailbox == "" {
+		mailbox = "INBOX"
+	}
+
+	return &imapSink{client: c, mailbox: mailbox, from: os.Getenv("IMAP_FROM")}, nil
+}
+
+//Write implements EntrySink.
+func (s *imapSink) Write(entry *atom.Entry) error {
+	msg := buildMIMEMessage(s.from, entry)
+	return s.client.Append(s.mailbox, nil, time.Now(), bytes.NewReader(msg))
+}
+
+//Close implements EntrySink.
+func (s *imapSink) Close() error {
+	return s.client.Logout()
+}
+
+//buildMIMEMessage renders entry as a minimal single-part MIME message
+//suitable for IMAP APPEND.
+func buildMIMEMessage(from string, entry *atom.Entry) []byte {
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "From: %s\r\n", from)
+	fmt.Fprintf(&buf, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", sanitizeHeaderValue(entry.ID)))
+	fmt.Fprintf(&buf, "Date: %s\r\n", sanitizeHeaderValue(string(entry.Published)))
+	fmt.Fprintf(&buf, "Content-Type: %s\r\n\r\n", sanitizeHeaderValue(entry.Content.Type))
+	buf.WriteString(entry.Content.Body)
+	return buf.Bytes()
+}
+
+//sanitizeHeaderValue strips CR and LF from a feed-controlled value
+//before it's interpolated into a raw MIME header line, so an entry ID
+//or content type containing "\r\n" can't inject extra headers or
+//smuggle a second message into the appended mail.
+func sanitizeHeaderValue(v string) string {
+	replacer := strings.NewReplacer("\r", "", "\n", "")
+	return replacer.Replace(v)
+}
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/Shopify/sarama"
+	"golang.org/x/tools/blog/atom"
+)
+
+//kafkaSink publishes each entry, marshaled the same way as ndjsonSink,
+//to Kafka, keyed by entry ID, so downstream consumers can fan the feed
+//out to other systems without every entry on a page carrying an
+//identical page-wide payload.
+type kafkaSink struct {
+	producer sarama.SyncProducer
+	topic    string
+}
+
+//newKafkaSink builds a kafkaSink from the KAFKA_BROKERS (comma
+//separated) and KAFKA_TOPIC env vars.
+func newKafkaSink() (*kafkaSink, error) {
+	brokersCSV := os.Getenv("KAFKA_BROKERS")
+	topic := os.Getenv("KAFKA_TOPIC")
+	if brokersCSV == "" || topic == "" {
+		return nil, fmt.Errorf("KAFKA_BROKERS and KAFKA_TOPIC must be set to use the kafka sink")
+	}
+
+	cfg := sarama.NewConfig()
+	cfg.Producer.Return.Successes = true
+
+	producer, err := sarama.NewSyncProducer(strings.Split(brokersCSV, ","), cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	return &kafkaSink{producer: producer, topic: topic}, nil
+}
+
+//Write implements EntrySink.
+func (s *kafkaSink) Write(entry *atom.Entry) error {
+	value, err := json.Marshal(newNDJSONRecord(entry))
+	if err != nil {
+		return err
+	}
+
+	_, _, err = s.producer.SendMessage(&sarama.ProducerMessage{
+		Topic: s.topic,
+		Key:   sarama.StringEncoder(entry.ID),
+		Value: sarama.ByteEncoder(value),
+	})
+
+	return err
+}
+
+//Close implements EntrySink.
+func (s *kafkaSink) Close() error {
+	return s.producer.Close()
+}
@@ -0,0 +1,50 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/nats-io/nats.go"
+	"golang.org/x/tools/blog/atom"
+)
+
+//natsSink publishes each entry, marshaled the same way as ndjsonSink,
+//on a NATS subject.
+type natsSink struct {
+	nc      *nats.Conn
+	subject string
+}
+
+//newNATSSink builds a natsSink from the NATS_URL and NATS_SUBJECT env
+//vars.
+func newNATSSink() (*natsSink, error) {
+	url := os.Getenv("NATS_URL")
+	subject := os.Getenv("NATS_SUBJECT")
+	if url == "" || subject == "" {
+		return nil, fmt.Errorf("NATS_URL and NATS_SUBJECT must be set to use the nats sink")
+	}
+
+	nc, err := nats.Connect(url)
+	if err != nil {
+		return nil, err
+	}
+
+	return &natsSink{nc: nc, subject: subject}, nil
+}
+
+//Write implements EntrySink.
+func (s *natsSink) Write(entry *atom.Entry) error {
+	value, err := json.Marshal(newNDJSONRecord(entry))
+	if err != nil {
+		return err
+	}
+
+	return s.nc.Publish(s.subject, value)
+}
+
+//Close implements EntrySink.
+func (s *natsSink) Close() error {
+	s.nc.Close()
+	return nil
+}
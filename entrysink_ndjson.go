@@ -0,0 +1,60 @@
+package main
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"io"
+	"unicode/utf8"
+
+	"golang.org/x/tools/blog/atom"
+)
+
+//ndjsonRecord is the JSON shape written, one per line, by ndjsonSink.
+type ndjsonRecord struct {
+	ID          string `json:"id"`
+	Published   string `json:"published"`
+	ContentType string `json:"contentType"`
+	Body        string `json:"body,omitempty"`
+	BodyBase64  string `json:"bodyBase64,omitempty"`
+}
+
+//ndjsonSink writes one JSON object per entry, newline-delimited, to an
+//io.Writer -- typically stdout.
+type ndjsonSink struct {
+	enc *json.Encoder
+}
+
+//newNDJSONSink builds an ndjsonSink writing to w.
+func newNDJSONSink(w io.Writer) *ndjsonSink {
+	return &ndjsonSink{enc: json.NewEncoder(w)}
+}
+
+//Write implements EntrySink.
+func (s *ndjsonSink) Write(entry *atom.Entry) error {
+	return s.enc.Encode(newNDJSONRecord(entry))
+}
+
+//newNDJSONRecord builds the JSON record for entry, falling back to
+//base64 when the body isn't valid UTF-8 so json.Marshal doesn't
+//silently mangle binary content.
+func newNDJSONRecord(entry *atom.Entry) ndjsonRecord {
+	record := ndjsonRecord{
+		ID:          entry.ID,
+		Published:   string(entry.Published),
+		ContentType: entry.Content.Type,
+	}
+
+	body := entry.Content.Body
+	if utf8.ValidString(body) {
+		record.Body = body
+	} else {
+		record.BodyBase64 = base64.StdEncoding.EncodeToString([]byte(body))
+	}
+
+	return record
+}
+
+//Close implements EntrySink.
+func (s *ndjsonSink) Close() error {
+	return nil
+}
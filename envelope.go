@@ -0,0 +1,149 @@
+package main
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+)
+
+//Envelope holds the pieces of an encrypted feed page recovered from the
+//wire format: the still-sealed data key and the ciphertext it protects,
+//plus whatever header metadata the codec understood.
+type Envelope struct {
+	EncryptedKey []byte
+	Ciphertext   []byte
+	KeyID        string
+	AAD          []byte
+}
+
+//EnvelopeCodec decodes the wire format publishers use to carry an
+//encrypted data key alongside the ciphertext it protects. Implementations
+//let the tool evolve the wire format without touching the decrypt path.
+type EnvelopeCodec interface {
+	Decode(feedBytes []byte) (*Envelope, error)
+}
+
+//legacyEnvelopeCodec implements the original wire format:
+//base64(encKey) :: base64(ciphertext). It carries no key ID or AAD.
+type legacyEnvelopeCodec struct{}
+
+//Decode implements EnvelopeCodec.
+func (legacyEnvelopeCodec) Decode(feedBytes []byte) (*Envelope, error) {
+	parts := bytes.SplitN(feedBytes, []byte("::"), 2)
+	if len(parts) != 2 {
+		return nil, errors.New(fmt.Sprintf("expected two parts, got %d", len(parts)))
+	}
+
+	keyBytes, err := base64.StdEncoding.DecodeString(string(parts[0]))
+	if err != nil {
+		return nil, err
+	}
+
+	msgBytes, err := base64.StdEncoding.DecodeString(string(parts[1]))
+	if err != nil {
+		return nil, err
+	}
+
+	return &Envelope{EncryptedKey: keyBytes, Ciphertext: msgBytes}, nil
+}
+
+//framedEnvelopeMagic identifies the versioned framed envelope format so it
+//can be distinguished from the legacy "::" separated format.
+var framedEnvelopeMagic = []byte("XDES1")
+
+//framedEnvelopeCodec implements a versioned, length-prefixed wire format:
+//
+//	magic(5) | keyIDLen(2) | keyID | aadLen(2) | aad |
+//	encKeyLen(4) | encKey | ciphertextLen(4) | ciphertext
+//
+//carrying the key ID/alias and optional AAD in the header instead of
+//relying on splitting on "::".
+type framedEnvelopeCodec struct{}
+
+//Decode implements EnvelopeCodec.
+func (framedEnvelopeCodec) Decode(feedBytes []byte) (*Envelope, error) {
+	if !bytes.HasPrefix(feedBytes, framedEnvelopeMagic) {
+		return nil, errors.New("not a framed envelope: missing magic prefix")
+	}
+
+	r := bytes.NewReader(feedBytes[len(framedEnvelopeMagic):])
+
+	keyID, err := readFramedSection16(r)
+	if err != nil {
+		return nil, fmt.Errorf("reading key id: %w", err)
+	}
+
+	aad, err := readFramedSection16(r)
+	if err != nil {
+		return nil, fmt.Errorf("reading aad: %w", err)
+	}
+
+	encKey, err := readFramedSection32(r)
+	if err != nil {
+		return nil, fmt.Errorf("reading encrypted key: %w", err)
+	}
+
+	ciphertext, err := readFramedSection32(r)
+	if err != nil {
+		return nil, fmt.Errorf("reading ciphertext: %w", err)
+	}
+
+	return &Envelope{
+		EncryptedKey: encKey,
+		Ciphertext:   ciphertext,
+		KeyID:        string(keyID),
+		AAD:          aad,
+	}, nil
+}
+
+func readFramedSection16(r *bytes.Reader) ([]byte, error) {
+	var length uint16
+	if err := binary.Read(r, binary.BigEndian, &length); err != nil {
+		return nil, err
+	}
+	return readFramedBytes(r, int(length))
+}
+
+func readFramedSection32(r *bytes.Reader) ([]byte, error) {
+	var length uint32
+	if err := binary.Read(r, binary.BigEndian, &length); err != nil {
+		return nil, err
+	}
+	return readFramedBytes(r, int(length))
+}
+
+func readFramedBytes(r *bytes.Reader, length int) ([]byte, error) {
+	buf := make([]byte, length)
+	if _, err := readFull(r, buf); err != nil {
+		return nil, err
+	}
+	return buf, nil
+}
+
+func readFull(r *bytes.Reader, buf []byte) (int, error) {
+	n := 0
+	for n < len(buf) {
+		read, err := r.Read(buf[n:])
+		n += read
+		if err != nil {
+			return n, err
+		}
+	}
+	return n, nil
+}
+
+//newEnvelopeCodec selects the EnvelopeCodec named by the ENVELOPE_FORMAT
+//env var, defaulting to the original legacy format for backward
+//compatibility with existing publishers.
+func newEnvelopeCodec(format string) (EnvelopeCodec, error) {
+	switch format {
+	case "", "legacy":
+		return legacyEnvelopeCodec{}, nil
+	case "framed":
+		return framedEnvelopeCodec{}, nil
+	default:
+		return nil, errors.New(fmt.Sprintf("unsupported ENVELOPE_FORMAT %q", format))
+	}
+}
@@ -0,0 +1,159 @@
+package main
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"net/http"
+	"sync"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+)
+
+//jwk is the subset of JSON Web Key fields this tool understands: EC
+//(P-256/P-384/P-521) and OKP (Ed25519) public keys.
+type jwk struct {
+	Kid string `json:"kid"`
+	Kty string `json:"kty"`
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+}
+
+type jwksDoc struct {
+	Keys []jwk `json:"keys"`
+}
+
+//jwksKeySet refreshes a set of named public keys from a JWKS URL on a
+//fixed interval, serving the most recently fetched set between refreshes.
+type jwksKeySet struct {
+	url          string
+	refreshEvery time.Duration
+
+	mu   sync.RWMutex
+	keys map[string]crypto.PublicKey
+}
+
+//newJWKSKeySet builds a jwksKeySet, performing an initial synchronous
+//fetch so the caller fails fast on a misconfigured URL.
+func newJWKSKeySet(url string, refreshEvery time.Duration) (*jwksKeySet, error) {
+	ks := &jwksKeySet{url: url, refreshEvery: refreshEvery, keys: make(map[string]crypto.PublicKey)}
+
+	if err := ks.refresh(); err != nil {
+		return nil, err
+	}
+
+	go ks.refreshLoop()
+
+	return ks, nil
+}
+
+func (ks *jwksKeySet) refreshLoop() {
+	ticker := time.NewTicker(ks.refreshEvery)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		if err := ks.refresh(); err != nil {
+			log.Warnf("Unable to refresh signing JWKS: %s", err.Error())
+		}
+	}
+}
+
+func (ks *jwksKeySet) refresh() error {
+	resp, err := http.Get(ks.url)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("fetching JWKS: unexpected status %d", resp.StatusCode)
+	}
+
+	var doc jwksDoc
+	if err := json.NewDecoder(resp.Body).Decode(&doc); err != nil {
+		return err
+	}
+
+	keys := make(map[string]crypto.PublicKey, len(doc.Keys))
+	for _, k := range doc.Keys {
+		pub, err := k.publicKey()
+		if err != nil {
+			log.Warnf("Skipping unsupported JWKS key %s: %s", k.Kid, err.Error())
+			continue
+		}
+		keys[k.Kid] = pub
+	}
+
+	ks.mu.Lock()
+	ks.keys = keys
+	ks.mu.Unlock()
+
+	return nil
+}
+
+//key looks up a public key by JWKS key id.
+func (ks *jwksKeySet) key(kid string) (crypto.PublicKey, bool) {
+	ks.mu.RLock()
+	defer ks.mu.RUnlock()
+	pub, ok := ks.keys[kid]
+	return pub, ok
+}
+
+func (k jwk) publicKey() (crypto.PublicKey, error) {
+	switch k.Kty {
+	case "OKP":
+		if k.Crv != "Ed25519" {
+			return nil, fmt.Errorf("unsupported OKP curve %s", k.Crv)
+		}
+
+		raw, err := base64.RawURLEncoding.DecodeString(k.X)
+		if err != nil {
+			return nil, err
+		}
+
+		return ed25519.PublicKey(raw), nil
+	case "EC":
+		curve, err := ecCurve(k.Crv)
+		if err != nil {
+			return nil, err
+		}
+
+		xBytes, err := base64.RawURLEncoding.DecodeString(k.X)
+		if err != nil {
+			return nil, err
+		}
+
+		yBytes, err := base64.RawURLEncoding.DecodeString(k.Y)
+		if err != nil {
+			return nil, err
+		}
+
+		return &ecdsa.PublicKey{
+			Curve: curve,
+			X:     new(big.Int).SetBytes(xBytes),
+			Y:     new(big.Int).SetBytes(yBytes),
+		}, nil
+	default:
+		return nil, fmt.Errorf("unsupported key type %s", k.Kty)
+	}
+}
+
+func ecCurve(name string) (elliptic.Curve, error) {
+	switch name {
+	case "P-256":
+		return elliptic.P256(), nil
+	case "P-384":
+		return elliptic.P384(), nil
+	case "P-521":
+		return elliptic.P521(), nil
+	default:
+		return nil, fmt.Errorf("unsupported EC curve %s", name)
+	}
+}
@@ -0,0 +1,39 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+//KeyService abstracts the envelope-encryption key backend used to unseal
+//the data encryption key (DEK) that protects a feed page. This lets the
+//tool consume feeds encrypted by publishers using a keystore other than
+//AWS KMS.
+type KeyService interface {
+	//UnsealDataKey decrypts an encrypted data key, returning the raw key
+	//bytes. aad carries optional additional authenticated data bound to
+	//the ciphertext by backends that support it (ignored otherwise).
+	UnsealDataKey(ctx context.Context, ciphertextBlob []byte, aad []byte) ([]byte, error)
+}
+
+//newKeyService builds the KeyService selected by the KEY_SERVICE env var.
+//keyURI is backend-specific: a KMS key alias/ARN, a Vault transit key
+//path, a GCP KMS resource name, an Azure Key Vault key identifier, or a
+//base64 data key for the static backend.
+func newKeyService(kind, keyURI string) (KeyService, error) {
+	switch kind {
+	case "", "kms", "awskms":
+		return newAWSKMSKeyService(keyURI)
+	case "vault":
+		return newVaultKeyService(keyURI)
+	case "gcpkms":
+		return newGCPKMSKeyService(keyURI)
+	case "azurekv":
+		return newAzureKeyVaultKeyService(keyURI)
+	case "static":
+		return newStaticKeyService(keyURI)
+	default:
+		return nil, errors.New(fmt.Sprintf("unsupported KEY_SERVICE %q", kind))
+	}
+}
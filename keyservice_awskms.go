@@ -0,0 +1,56 @@
+package main
+
+import (
+	"context"
+	"errors"
+
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/kms"
+)
+
+//awsKMSKeyService unseals data keys via AWS KMS, the tool's original and
+//default backend.
+type awsKMSKeyService struct {
+	svc      *kms.KMS
+	keyAlias string
+}
+
+//newAWSKMSKeyService builds a KeyService backed by AWS KMS. keyAlias is
+//retained for logging/diagnostics only -- KMS determines the key to use
+//from the ciphertext blob itself.
+func newAWSKMSKeyService(keyAlias string) (KeyService, error) {
+	sess, err := session.NewSession()
+	if err != nil {
+		return nil, err
+	}
+
+	return &awsKMSKeyService{svc: kms.New(sess), keyAlias: keyAlias}, nil
+}
+
+//UnsealDataKey implements KeyService.
+func (k *awsKMSKeyService) UnsealDataKey(ctx context.Context, ciphertextBlob []byte, aad []byte) ([]byte, error) {
+	if k.svc == nil {
+		return nil, errors.New("aws kms key service not configured")
+	}
+
+	di := &kms.DecryptInput{
+		CiphertextBlob: ciphertextBlob,
+	}
+
+	if len(aad) > 0 {
+		di.EncryptionContext = map[string]*string{
+			"aad": stringPtr(string(aad)),
+		}
+	}
+
+	out, err := k.svc.DecryptWithContext(ctx, di)
+	if err != nil {
+		return nil, err
+	}
+
+	return out.Plaintext, nil
+}
+
+func stringPtr(s string) *string {
+	return &s
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+
+	"github.com/Azure/azure-sdk-for-go/services/keyvault/2016-10-01/keyvault"
+	"github.com/Azure/go-autorest/autorest/azure/auth"
+)
+
+//azureKeyVaultKeyService unseals data keys via Azure Key Vault. keyURI is
+//the key identifier in "https://<vault>.vault.azure.net/keys/<name>/<version>"
+//form.
+type azureKeyVaultKeyService struct {
+	client  keyvault.BaseClient
+	keyURI  string
+	keyAlgo keyvault.JSONWebKeyEncryptionAlgorithm
+}
+
+//newAzureKeyVaultKeyService builds a KeyService backed by Azure Key Vault.
+//Credentials are resolved from the environment via auth.NewAuthorizerFromEnvironment.
+func newAzureKeyVaultKeyService(keyURI string) (KeyService, error) {
+	if keyURI == "" {
+		return nil, errors.New("KEY_URI must name an Azure Key Vault key identifier")
+	}
+
+	authorizer, err := auth.NewAuthorizerFromEnvironment()
+	if err != nil {
+		return nil, err
+	}
+
+	client := keyvault.New()
+	client.Authorizer = authorizer
+
+	return &azureKeyVaultKeyService{
+		client:  client,
+		keyURI:  keyURI,
+		keyAlgo: keyvault.RSAOAEP256,
+	}, nil
+}
+
+//UnsealDataKey implements KeyService. Azure Key Vault's RSA-OAEP Decrypt
+//operation has no AAD parameter, so unlike KMS/Vault-backed KeyServices
+//this one cannot bind aad to the ciphertext; rather than silently
+//dropping it, a non-empty aad is rejected.
+func (a *azureKeyVaultKeyService) UnsealDataKey(ctx context.Context, ciphertextBlob []byte, aad []byte) ([]byte, error) {
+	if len(aad) > 0 {
+		return nil, errors.New("azureKeyVaultKeyService does not support AAD-bound decryption")
+	}
+
+	vaultBaseURL, keyName, keyVersion, err := splitAzureKeyURI(a.keyURI)
+	if err != nil {
+		return nil, err
+	}
+
+	encoded := base64.RawURLEncoding.EncodeToString(ciphertextBlob)
+	params := keyvault.KeyOperationsParameters{
+		Algorithm: a.keyAlgo,
+		Value:     &encoded,
+	}
+
+	result, err := a.client.Decrypt(ctx, vaultBaseURL, keyName, keyVersion, params)
+	if err != nil {
+		return nil, err
+	}
+
+	return base64.RawURLEncoding.DecodeString(*result.Result)
+}
+
+//splitAzureKeyURI breaks a key identifier of the form
+//"https://<vault>.vault.azure.net/keys/<name>/<version>" into the vault
+//base URL, key name, and key version expected by the Key Vault SDK.
+func splitAzureKeyURI(keyURI string) (vaultBaseURL, keyName, keyVersion string, err error) {
+	parsed, err := url.Parse(keyURI)
+	if err != nil {
+		return "", "", "", err
+	}
+
+	parts := strings.Split(strings.Trim(parsed.Path, "/"), "/")
+	if len(parts) != 3 || parts[0] != "keys" {
+		return "", "", "", errors.New(fmt.Sprintf("malformed Azure Key Vault key identifier %q", keyURI))
+	}
+
+	vaultBaseURL = fmt.Sprintf("%s://%s", parsed.Scheme, parsed.Host)
+	return vaultBaseURL, parts[1], parts[2], nil
+}
@@ -0,0 +1,30 @@
+package main
+
+import "context"
+
+//fallbackKeyService tries a primary KeyService first, falling back in
+//order to previously-valid key aliases (configured via
+//KEY_ALIAS_FALLBACKS) when the primary fails -- typically because the KEK
+//has since been rotated away from under already-published feed pages.
+type fallbackKeyService struct {
+	primary   KeyService
+	fallbacks []KeyService
+}
+
+//UnsealDataKey implements KeyService.
+func (f *fallbackKeyService) UnsealDataKey(ctx context.Context, ciphertextBlob []byte, aad []byte) ([]byte, error) {
+	key, err := f.primary.UnsealDataKey(ctx, ciphertextBlob, aad)
+	if err == nil {
+		return key, nil
+	}
+
+	lastErr := err
+	for _, fb := range f.fallbacks {
+		key, lastErr = fb.UnsealDataKey(ctx, ciphertextBlob, aad)
+		if lastErr == nil {
+			return key, nil
+		}
+	}
+
+	return nil, lastErr
+}
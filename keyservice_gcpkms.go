@@ -0,0 +1,48 @@
+package main
+
+import (
+	"context"
+	"errors"
+
+	kmspb "cloud.google.com/go/kms/apiv1"
+	kmspb2 "google.golang.org/genproto/googleapis/cloud/kms/v1"
+)
+
+//gcpKMSKeyService unseals data keys via GCP Cloud KMS. keyURI is the full
+//resource name of the crypto key, e.g.
+//"projects/p/locations/l/keyRings/r/cryptoKeys/k".
+type gcpKMSKeyService struct {
+	client *kmspb.KeyManagementClient
+	name   string
+}
+
+//newGCPKMSKeyService builds a KeyService backed by GCP Cloud KMS.
+//Credentials are resolved the usual way via GOOGLE_APPLICATION_CREDENTIALS.
+func newGCPKMSKeyService(keyURI string) (KeyService, error) {
+	if keyURI == "" {
+		return nil, errors.New("KEY_URI must name a GCP KMS key resource")
+	}
+
+	client, err := kmspb.NewKeyManagementClient(context.Background())
+	if err != nil {
+		return nil, err
+	}
+
+	return &gcpKMSKeyService{client: client, name: keyURI}, nil
+}
+
+//UnsealDataKey implements KeyService.
+func (g *gcpKMSKeyService) UnsealDataKey(ctx context.Context, ciphertextBlob []byte, aad []byte) ([]byte, error) {
+	req := &kmspb2.DecryptRequest{
+		Name:                        g.name,
+		Ciphertext:                  ciphertextBlob,
+		AdditionalAuthenticatedData: aad,
+	}
+
+	resp, err := g.client.Decrypt(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+
+	return resp.Plaintext, nil
+}
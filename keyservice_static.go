@@ -0,0 +1,43 @@
+package main
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"os"
+)
+
+//staticKeyService returns a fixed, pre-shared data key instead of
+//unsealing one. It exists so the rest of the pipeline -- envelope
+//decoding, AES-GCM decryption, sinks -- can be exercised in tests and
+//local development without a live KMS.
+type staticKeyService struct {
+	key []byte
+}
+
+//newStaticKeyService builds a KeyService that always returns the key
+//found base64-encoded in the KEY_URI value (or, if empty, in the
+//STATIC_DATA_KEY env var).
+func newStaticKeyService(keyURI string) (KeyService, error) {
+	encoded := keyURI
+	if encoded == "" {
+		encoded = os.Getenv("STATIC_DATA_KEY")
+	}
+
+	if encoded == "" {
+		return nil, errors.New("static key service requires KEY_URI or STATIC_DATA_KEY to hold a base64 data key")
+	}
+
+	key, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, err
+	}
+
+	return &staticKeyService{key: key}, nil
+}
+
+//UnsealDataKey implements KeyService by ignoring the ciphertext and
+//returning the configured static key.
+func (s *staticKeyService) UnsealDataKey(ctx context.Context, ciphertextBlob []byte, aad []byte) ([]byte, error) {
+	return s.key, nil
+}
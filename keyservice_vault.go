@@ -0,0 +1,70 @@
+package main
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"os"
+
+	vaultapi "github.com/hashicorp/vault/api"
+)
+
+//vaultKeyService unseals data keys via HashiCorp Vault's transit secrets
+//engine. keyURI is the transit decrypt path, e.g. "transit/decrypt/my-key".
+type vaultKeyService struct {
+	client  *vaultapi.Client
+	keyPath string
+}
+
+//newVaultKeyService builds a KeyService backed by Vault transit. Vault
+//address and token are taken from the standard VAULT_ADDR/VAULT_TOKEN
+//env vars via vaultapi.DefaultConfig.
+func newVaultKeyService(keyURI string) (KeyService, error) {
+	if keyURI == "" {
+		return nil, errors.New("KEY_URI must name a vault transit key path")
+	}
+
+	cfg := vaultapi.DefaultConfig()
+	if err := cfg.ReadEnvironment(); err != nil {
+		return nil, err
+	}
+
+	client, err := vaultapi.NewClient(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	if token := os.Getenv("VAULT_TOKEN"); token != "" {
+		client.SetToken(token)
+	}
+
+	return &vaultKeyService{client: client, keyPath: keyURI}, nil
+}
+
+//UnsealDataKey implements KeyService.
+func (v *vaultKeyService) UnsealDataKey(ctx context.Context, ciphertextBlob []byte, aad []byte) ([]byte, error) {
+	data := map[string]interface{}{
+		"ciphertext": string(ciphertextBlob),
+	}
+
+	if len(aad) > 0 {
+		data["context"] = base64.StdEncoding.EncodeToString(aad)
+	}
+
+	secret, err := v.client.Logical().WriteWithContext(ctx, v.keyPath, data)
+	if err != nil {
+		return nil, err
+	}
+
+	if secret == nil || secret.Data["plaintext"] == nil {
+		return nil, errors.New(fmt.Sprintf("vault transit response for %s had no plaintext", v.keyPath))
+	}
+
+	encoded, ok := secret.Data["plaintext"].(string)
+	if !ok {
+		return nil, errors.New("vault transit plaintext was not a string")
+	}
+
+	return base64.StdEncoding.DecodeString(encoded)
+}
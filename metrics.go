@@ -0,0 +1,43 @@
+package main
+
+import (
+	"expvar"
+	"fmt"
+	"net/http"
+
+	log "github.com/Sirupsen/logrus"
+)
+
+var (
+	dekCacheHits                   = expvar.NewInt("dek_cache_hits")
+	dekCacheMisses                 = expvar.NewInt("dek_cache_misses")
+	keyServiceCalls                = expvar.NewInt("key_service_calls")
+	signatureVerificationFailures  = expvar.NewInt("signature_verification_failures")
+)
+
+//maybeServeMetrics starts an HTTP server exposing cache hit rate and
+//KeyService call count at addr, if configured via METRICS_ADDR. The
+//expvar package registers its own handler under /debug/vars on the
+//default ServeMux; /metrics serves the same counters in a minimal
+//Prometheus text exposition format.
+func maybeServeMetrics(addr string) {
+	if addr == "" {
+		return
+	}
+
+	http.HandleFunc("/metrics", prometheusMetricsHandler)
+
+	go func() {
+		log.Infof("Serving metrics on %s", addr)
+		if err := http.ListenAndServe(addr, nil); err != nil {
+			log.Errorf("Metrics server stopped: %s", err.Error())
+		}
+	}()
+}
+
+func prometheusMetricsHandler(w http.ResponseWriter, r *http.Request) {
+	fmt.Fprintf(w, "dumpeventstore_dek_cache_hits %d\n", dekCacheHits.Value())
+	fmt.Fprintf(w, "dumpeventstore_dek_cache_misses %d\n", dekCacheMisses.Value())
+	fmt.Fprintf(w, "dumpeventstore_key_service_calls %d\n", keyServiceCalls.Value())
+	fmt.Fprintf(w, "dumpeventstore_signature_verification_failures %d\n", signatureVerificationFailures.Value())
+}
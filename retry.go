@@ -0,0 +1,77 @@
+package main
+
+import (
+	"math/rand"
+	"net/http"
+	"strconv"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+	"golang.org/x/time/rate"
+)
+
+//maxFetchRetries bounds the number of attempts doRequestWithRetry makes
+//for a transient 5xx or 429 response before giving up.
+const maxFetchRetries = 5
+
+const (
+	retryBaseDelay = 250 * time.Millisecond
+	retryMaxDelay  = 10 * time.Second
+)
+
+//newRateLimiterFromEnv builds the *rate.Limiter shared by all requests a
+//reader makes, from RATE_LIMIT_RPS and RATE_LIMIT_BURST. An unset or
+//non-positive RATE_LIMIT_RPS leaves requests unthrottled.
+func newRateLimiterFromEnv() *rate.Limiter {
+	rps := envFloat("RATE_LIMIT_RPS", 0)
+	if rps <= 0 {
+		return rate.NewLimiter(rate.Inf, 0)
+	}
+
+	burst := envInt("RATE_LIMIT_BURST", 1)
+
+	return rate.NewLimiter(rate.Limit(rps), burst)
+}
+
+//isRetryableStatus reports whether status is a transient condition worth
+//retrying: 429 Too Many Requests, or any 5xx server error.
+func isRetryableStatus(status int) bool {
+	return status == http.StatusTooManyRequests || (status >= 500 && status < 600)
+}
+
+//retryAfterDelay parses a Retry-After header's delay-seconds form,
+//returning 0 (meaning "fall back to backoffWithJitter") if header is
+//empty or not a plain integer.
+func retryAfterDelay(header string) time.Duration {
+	if header == "" {
+		return 0
+	}
+
+	seconds, err := strconv.Atoi(header)
+	if err != nil || seconds < 0 {
+		return 0
+	}
+
+	return time.Duration(seconds) * time.Second
+}
+
+//backoffWithJitter returns an exponential backoff delay for the given
+//zero-based attempt number, capped at retryMaxDelay, with up to 50%
+//added jitter so a fleet of readers hitting the same outage doesn't
+//retry in lockstep.
+func backoffWithJitter(attempt int) time.Duration {
+	delay := retryBaseDelay << uint(attempt)
+	if delay > retryMaxDelay || delay <= 0 {
+		delay = retryMaxDelay
+	}
+
+	jitter := time.Duration(rand.Int63n(int64(delay)/2 + 1))
+
+	return delay + jitter
+}
+
+//logRetry reports a retryable response and the delay before the next
+//attempt.
+func logRetry(url string, status, attempt, maxAttempts int, delay time.Duration) {
+	log.Warnf("Retryable status %d from %s, retrying in %s (attempt %d/%d)", status, url, delay, attempt+1, maxAttempts)
+}
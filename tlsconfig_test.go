@@ -0,0 +1,106 @@
+package main
+
+import (
+	"encoding/pem"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+//writeTestCABundle PEM-encodes the TLS test server's certificate to a
+//temp file so it can be passed to TLSConfig as CA_BUNDLE.
+func writeTestCABundle(t *testing.T, srv *httptest.Server) string {
+	t.Helper()
+
+	dir := t.TempDir()
+	path := filepath.Join(dir, "ca.pem")
+
+	block := &pem.Block{Type: "CERTIFICATE", Bytes: srv.Certificate().Raw}
+	if err := ioutil.WriteFile(path, pem.EncodeToMemory(block), 0644); err != nil {
+		t.Fatalf("writing CA bundle: %v", err)
+	}
+
+	return path
+}
+
+func getViaTLSConfig(t *testing.T, srv *httptest.Server, cfg TLSConfig) (*http.Response, error) {
+	t.Helper()
+
+	tlsCfg, err := cfg.Build()
+	if err != nil {
+		t.Fatalf("TLSConfig.Build: %v", err)
+	}
+
+	tr, err := newHTTPTransport(tlsCfg)
+	if err != nil {
+		t.Fatalf("newHTTPTransport: %v", err)
+	}
+
+	client := &http.Client{Transport: tr}
+	return client.Get(srv.URL)
+}
+
+func TestTLSConfigRejectsUntrustedServerByDefault(t *testing.T) {
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer srv.Close()
+
+	_, err := getViaTLSConfig(t, srv, TLSConfig{})
+	if err == nil {
+		t.Fatal("expected certificate verification to fail against an unknown CA, got nil error")
+	}
+}
+
+func TestTLSConfigAcceptsServerWithMatchingCABundle(t *testing.T) {
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer srv.Close()
+
+	caPath := writeTestCABundle(t, srv)
+
+	resp, err := getViaTLSConfig(t, srv, TLSConfig{CABundlePath: caPath})
+	if err != nil {
+		t.Fatalf("expected request to succeed with a trusted CA bundle, got: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("got status %d, want %d", resp.StatusCode, http.StatusOK)
+	}
+}
+
+func TestTLSConfigInsecureSkipVerifyBypassesVerification(t *testing.T) {
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer srv.Close()
+
+	resp, err := getViaTLSConfig(t, srv, TLSConfig{InsecureSkipVerify: true})
+	if err != nil {
+		t.Fatalf("expected request to succeed with InsecureSkipVerify, got: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("got status %d, want %d", resp.StatusCode, http.StatusOK)
+	}
+}
+
+func TestTLSConfigMissingCABundleFileErrors(t *testing.T) {
+	_, err := TLSConfig{CABundlePath: filepath.Join(os.TempDir(), "does-not-exist.pem")}.Build()
+	if err == nil {
+		t.Fatal("expected an error for a missing CA bundle file, got nil")
+	}
+}
+
+func TestTLSConfigRequiresBothClientCertAndKey(t *testing.T) {
+	_, err := TLSConfig{ClientCertPath: "cert.pem"}.Build()
+	if err == nil {
+		t.Fatal("expected an error when CLIENT_CERT is set without CLIENT_KEY, got nil")
+	}
+}
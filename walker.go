@@ -0,0 +1,171 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+	"golang.org/x/sync/errgroup"
+	"golang.org/x/tools/blog/atom"
+)
+
+//Delivery mode for checkpointing: at-least-once checkpoints once per
+//archive page (an interrupted run may re-deliver the page's entries),
+//at-most-once checkpoints each entry before handing it to the sink (an
+//interrupted run never re-delivers an entry but may lose the one in
+//flight, and pays a checkpoint write per entry).
+const (
+	atLeastOnce = "at-least-once"
+	atMostOnce  = "at-most-once"
+)
+
+//walkFlags holds the --from/--to/--mode command line options governing a
+//resumable walk.
+type walkFlags struct {
+	from time.Time
+	to   time.Time
+	mode string
+}
+
+//parseWalkFlags parses the --from, --to, and --mode flags. --from and
+//--to are RFC3339 timestamps bounding which entries are emitted; either
+//may be omitted to leave that bound open.
+func parseWalkFlags() walkFlags {
+	from := flag.String("from", "", "only emit entries published at or after this RFC3339 time")
+	to := flag.String("to", "", "only emit entries published at or before this RFC3339 time")
+	mode := flag.String("mode", atLeastOnce, "checkpoint delivery mode: at-least-once or at-most-once")
+	flag.Parse()
+
+	var flags walkFlags
+	flags.mode = *mode
+
+	if *from != "" {
+		t, err := time.Parse(time.RFC3339, *from)
+		if err != nil {
+			log.Fatalf("Invalid --from %q: %s", *from, err.Error())
+		}
+		flags.from = t
+	}
+
+	if *to != "" {
+		t, err := time.Parse(time.RFC3339, *to)
+		if err != nil {
+			log.Fatalf("Invalid --to %q: %s", *to, err.Error())
+		}
+		flags.to = t
+	}
+
+	return flags
+}
+
+//currentFeedID extracts the feed's own id from its self link, for
+//recording in a Checkpoint.
+func currentFeedID(feed *atom.Feed) string {
+	self := getLink("self", feed)
+	if self == nil {
+		return ""
+	}
+
+	return feedIdFromResource(*self)
+}
+
+//FeedWalker walks a chain of archive feeds forward, overlapping the
+//network fetch of upcoming archives with the caller's processing of the
+//current one. An archive's id is only discoverable from the previous
+//archive's next-archive link, so archives can't be requested out of
+//order; FeedWalker instead runs a single fetcher goroutine that walks
+//the chain as fast as the network and its retry/rate-limit policy allow,
+//feeding a channel buffered to keep up to prefetch archives fetched
+//ahead of emit.
+type FeedWalker struct {
+	reader   *HttpFeedReader
+	prefetch int
+}
+
+//NewFeedWalker builds a FeedWalker that keeps up to prefetch archives
+//fetched ahead of emit. prefetch is clamped to at least 1.
+func NewFeedWalker(reader *HttpFeedReader, prefetch int) *FeedWalker {
+	if prefetch < 1 {
+		prefetch = 1
+	}
+
+	return &FeedWalker{reader: reader, prefetch: prefetch}
+}
+
+//Walk emits first, then follows next-archive links until the chain ends,
+//ctx is cancelled, or emit returns an error. On cancellation, any
+//archives already fetched and buffered are still handed to emit -- so a
+//caller checkpointing inside emit never loses a page it already has in
+//hand -- before Walk returns ctx's error.
+func (w *FeedWalker) Walk(ctx context.Context, first *atom.Feed, emit func(*atom.Feed) error) error {
+	pages := make(chan *atom.Feed, w.prefetch)
+
+	g, gctx := errgroup.WithContext(ctx)
+
+	g.Go(func() error {
+		defer close(pages)
+
+		feed := first
+		for {
+			select {
+			case pages <- feed:
+			case <-gctx.Done():
+				return gctx.Err()
+			}
+
+			next := getLink("next-archive", feed)
+			if next == nil {
+				return nil
+			}
+
+			nextFeedID := feedIdFromResource(*next)
+			log.Infof("Next archive feed id is %s", nextFeedID)
+
+			var err error
+			feed, err = w.reader.GetFeed(gctx, nextFeedID)
+			if err != nil {
+				if ctx.Err() != nil {
+					return ctx.Err()
+				}
+				return err
+			}
+		}
+	})
+
+	g.Go(func() error {
+		for feed := range pages {
+			if err := emit(feed); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+
+	return g.Wait()
+}
+
+//withinBounds reports whether entry's published time falls within
+//[from, to], treating a zero from or to as unbounded and an unparseable
+//published time as always in bounds.
+func withinBounds(entry *atom.Entry, from, to time.Time) bool {
+	if from.IsZero() && to.IsZero() {
+		return true
+	}
+
+	published, err := time.Parse(time.RFC3339, string(entry.Published))
+	if err != nil {
+		return true
+	}
+
+	if !from.IsZero() && published.Before(from) {
+		return false
+	}
+
+	if !to.IsZero() && published.After(to) {
+		return false
+	}
+
+	return true
+}